@@ -0,0 +1,209 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// downloadArchive fetches url into a temporary file and returns its path.
+// The temp file is named with url's archive extension so extractArchive can
+// later dispatch on it. The caller is responsible for removing it.
+func downloadArchive(url string) (string, error) {
+	ext, err := archiveExtension(url)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s downloading %s", resp.Status, url)
+	}
+
+	tmp, err := ioutil.TempFile("", "watts-plugin-tester-download-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// archiveExtension returns the archive extension url ends in, matched
+// against the same suffixes extractArchive dispatches on.
+func archiveExtension(url string) (string, error) {
+	switch {
+	case strings.HasSuffix(url, ".tar.gz"):
+		return ".tar.gz", nil
+	case strings.HasSuffix(url, ".tgz"):
+		return ".tgz", nil
+	case strings.HasSuffix(url, ".tar"):
+		return ".tar", nil
+	case strings.HasSuffix(url, ".zip"):
+		return ".zip", nil
+	default:
+		return "", fmt.Errorf("unsupported archive format for %s", url)
+	}
+}
+
+func verifyChecksum(path, expectedSha256 string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, expectedSha256) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedSha256, actual)
+	}
+	return nil
+}
+
+// extractArchive extracts a zip or tar(.gz) archive at archivePath into
+// destDir, picked by the archive's file extension.
+func extractArchive(archivePath, destDir string) error {
+	err := os.MkdirAll(destDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case strings.HasSuffix(archivePath, ".zip"):
+		return extractZip(archivePath, destDir)
+	case strings.HasSuffix(archivePath, ".tar.gz"), strings.HasSuffix(archivePath, ".tgz"):
+		return extractTarGz(archivePath, destDir)
+	case strings.HasSuffix(archivePath, ".tar"):
+		return extractTar(archivePath, destDir)
+	default:
+		return fmt.Errorf("unsupported archive format for %s", archivePath)
+	}
+}
+
+func extractZip(archivePath, destDir string) error {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		target := filepath.Join(destDir, f.Name)
+		if !strings.HasPrefix(target, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry %s escapes destination directory", f.Name)
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(target, rc, f.Mode())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarGz(archivePath, destDir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return extractTarReader(tar.NewReader(gz), destDir)
+}
+
+func extractTar(archivePath, destDir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return extractTarReader(tar.NewReader(f), destDir)
+}
+
+func extractTarReader(tr *tar.Reader, destDir string) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(destDir, hdr.Name)
+		if !strings.HasPrefix(target, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry %s escapes destination directory", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, os.FileMode(hdr.Mode)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, r)
+	return err
+}
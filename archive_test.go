@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestArchiveExtension(t *testing.T) {
+	cases := map[string]string{
+		"https://example.org/plugin.zip":                 ".zip",
+		"https://example.org/plugin.tar.gz":              ".tar.gz",
+		"https://example.org/plugin.tgz":                 ".tgz",
+		"https://example.org/plugin.tar":                 ".tar",
+		"https://example.org/releases/plugin-v1.0.0.zip": ".zip",
+	}
+
+	for url, want := range cases {
+		got, err := archiveExtension(url)
+		if err != nil {
+			t.Fatalf("archiveExtension(%q): unexpected error: %s", url, err)
+		}
+		if got != want {
+			t.Errorf("archiveExtension(%q) = %q, want %q", url, got, want)
+		}
+	}
+
+	if _, err := archiveExtension("https://example.org/plugin"); err == nil {
+		t.Error("archiveExtension: expected an error for an unrecognized extension")
+	}
+}
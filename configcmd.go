@@ -0,0 +1,66 @@
+package main
+
+import "os"
+
+var (
+	pluginConfig = app.Command("config", "Manage persistent per-plugin tester defaults")
+
+	configSet      = pluginConfig.Command("set", "Set a config value for a plugin")
+	configSetName  = configSet.Arg("plugin", "Name of the plugin").Required().String()
+	configSetKey   = configSet.Arg("key", "Config key").Required().String()
+	configSetValue = configSet.Arg("value", "Config value").Required().String()
+
+	configGet     = pluginConfig.Command("get", "Get a config value for a plugin")
+	configGetName = configGet.Arg("plugin", "Name of the plugin").Required().String()
+	configGetKey  = configGet.Arg("key", "Config key").Required().String()
+
+	configUnset     = pluginConfig.Command("unset", "Remove a config value for a plugin")
+	configUnsetName = configUnset.Arg("plugin", "Name of the plugin").Required().String()
+	configUnsetKey  = configUnset.Arg("key", "Config key").Required().String()
+
+	configList     = pluginConfig.Command("list", "List the stored config, for one plugin or all of them")
+	configListName = configList.Arg("plugin", "Name of the plugin").String()
+)
+
+func configSetCommand(globalOutput jsonObject, name, key, value string) {
+	cfg := loadToolConfig()
+	if cfg.PluginConfig[name] == nil {
+		cfg.PluginConfig[name] = map[string]string{}
+	}
+	cfg.PluginConfig[name][key] = value
+	cfg.save()
+
+	globalOutput.print("result", "ok")
+	globalOutput.print("plugin_config", cfg.PluginConfig[name])
+}
+
+func configGetCommand(globalOutput jsonObject, name, key string) {
+	cfg := loadToolConfig()
+	value, found := cfg.PluginConfig[name][key]
+	if !found {
+		app.Errorf("no config value '%s' stored for plugin %s", key, name)
+		os.Exit(exitCodeUserError)
+	}
+
+	globalOutput.print("result", "ok")
+	globalOutput.print(key, value)
+}
+
+func configUnsetCommand(globalOutput jsonObject, name, key string) {
+	cfg := loadToolConfig()
+	delete(cfg.PluginConfig[name], key)
+	cfg.save()
+
+	globalOutput.print("result", "ok")
+}
+
+func configListCommand(globalOutput jsonObject, name string) {
+	cfg := loadToolConfig()
+
+	globalOutput.print("result", "ok")
+	if name == "" {
+		globalOutput.print("plugin_config", cfg.PluginConfig)
+		return
+	}
+	globalOutput.print("plugin_config", cfg.PluginConfig[name])
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	pluginInstall        = app.Command("install", "Install a plugin from the configured channels")
+	pluginInstallName    = pluginInstall.Arg("name", "Name of the plugin to install").Required().String()
+	pluginInstallVersion = pluginInstall.Flag("version", "Specific version to install, defaults to the latest").String()
+
+	pluginSearch     = app.Command("search", "Search the configured channels for a plugin")
+	pluginSearchTerm = pluginSearch.Arg("term", "Term to search for in plugin name, description and tags").Required().String()
+
+	pluginList = app.Command("list", "List installed plugins")
+
+	pluginUpgrade     = app.Command("upgrade", "Upgrade an installed plugin to the latest version")
+	pluginUpgradeName = pluginUpgrade.Arg("name", "Name of the plugin to upgrade").Required().String()
+
+	pluginRemove     = app.Command("remove", "Remove an installed plugin")
+	pluginRemoveName = pluginRemove.Arg("name", "Name of the plugin to remove").Required().String()
+
+	pluginChannel    = app.Command("channel", "Manage plugin channels")
+	channelAdd       = pluginChannel.Command("add", "Add a channel")
+	channelAddURL    = channelAdd.Arg("url", "URL of the channel index").Required().String()
+	channelRemove    = pluginChannel.Command("remove", "Remove a channel")
+	channelRemoveURL = channelRemove.Arg("url", "URL of the channel to remove").Required().String()
+	channelList      = pluginChannel.Command("list", "List configured channels")
+)
+
+func addChannel(globalOutput jsonObject, url string) {
+	channels := loadChannels()
+	for _, c := range channels {
+		if c == url {
+			globalOutput.print("result", "ok")
+			globalOutput.print("description", "channel already configured")
+			return
+		}
+	}
+	channels = append(channels, url)
+	saveChannels(channels)
+
+	globalOutput.print("result", "ok")
+	globalOutput.print("channels", channels)
+}
+
+func removeChannel(globalOutput jsonObject, url string) {
+	channels := loadChannels()
+	remaining := make([]string, 0, len(channels))
+	for _, c := range channels {
+		if c != url {
+			remaining = append(remaining, c)
+		}
+	}
+	saveChannels(remaining)
+
+	globalOutput.print("result", "ok")
+	globalOutput.print("channels", remaining)
+}
+
+func listChannels(globalOutput jsonObject) {
+	globalOutput.print("result", "ok")
+	globalOutput.print("channels", loadChannels())
+}
+
+// installPlugin resolves name (and its transitive dependencies) against the
+// configured channels, downloads, verifies and extracts each one, and
+// records the result in the lockfile.
+func installPlugin(globalOutput jsonObject, name, constraint string) {
+	channels := loadChannels()
+	if len(channels) == 0 {
+		app.Errorf("no channels configured, add one with 'watts-plugin-tester channel add <url>'")
+		os.Exit(exitCodeUserError)
+	}
+
+	repos, err := fetchAllRepositories(channels)
+	check(err, exitCodeInternalError, "fetching channels")
+
+	plan, err := resolveDependencies(repos, name, constraint)
+	check(err, exitCodeUserError, "resolving dependencies")
+
+	lock := loadLockfile()
+	installed := []string{}
+	for pluginName, version := range plan {
+		installDir := pluginInstallDir(pluginName, version.Version)
+
+		archivePath, err := downloadArchive(version.URL)
+		check(err, exitCodeInternalError, fmt.Sprintf("downloading %s", pluginName))
+		defer os.Remove(archivePath)
+
+		check(verifyChecksum(archivePath, version.Checksum), exitCodePluginError,
+			fmt.Sprintf("verifying checksum of %s", pluginName))
+
+		check(extractArchive(archivePath, installDir), exitCodeInternalError,
+			fmt.Sprintf("extracting %s", pluginName))
+
+		pluginPath := filepath.Join(installDir, pluginName)
+		if _, err := os.Stat(pluginPath); err != nil {
+			app.Errorf("%s archive did not produce the expected plugin file at %s", pluginName, pluginPath)
+			os.Exit(exitCodeInternalError)
+		}
+
+		lock.Plugins[pluginName] = LockedPlugin{
+			Version: version.Version,
+			Path:    pluginPath,
+		}
+		installed = append(installed, fmt.Sprintf("%s@%s", pluginName, version.Version))
+	}
+	lock.save()
+
+	globalOutput.print("result", "ok")
+	globalOutput.print("installed", installed)
+}
+
+func searchPlugins(globalOutput jsonObject, term string) {
+	channels := loadChannels()
+	repos, err := fetchAllRepositories(channels)
+	check(err, exitCodeInternalError, "fetching channels")
+
+	matches := []jsonObject{}
+	for _, repo := range repos {
+		for _, p := range repo.Plugins {
+			if pluginMatchesSearch(p, term) {
+				matches = append(matches, jsonObject{
+					"name":        p.Name,
+					"description": p.Description,
+					"author":      p.Author,
+					"tags":        p.Tags,
+				})
+			}
+		}
+	}
+
+	globalOutput.print("result", "ok")
+	globalOutput.print("matches", matches)
+}
+
+func pluginMatchesSearch(p PluginPackage, term string) bool {
+	if contains(p.Name, term) || contains(p.Description, term) {
+		return true
+	}
+	for _, tag := range p.Tags {
+		if contains(tag, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(haystack, needle string) bool {
+	return strings.Contains(strings.ToLower(haystack), strings.ToLower(needle))
+}
+
+func listInstalledPlugins(globalOutput jsonObject) {
+	lock := loadLockfile()
+
+	plugins := []jsonObject{}
+	for name, locked := range lock.Plugins {
+		plugins = append(plugins, jsonObject{
+			"name":    name,
+			"version": locked.Version,
+			"path":    locked.Path,
+		})
+	}
+
+	globalOutput.print("result", "ok")
+	globalOutput.print("plugins", plugins)
+}
+
+func upgradePlugin(globalOutput jsonObject, name string) {
+	lock := loadLockfile()
+	if _, found := lock.Plugins[name]; !found {
+		app.Errorf("plugin %s is not installed", name)
+		os.Exit(exitCodeUserError)
+	}
+	installPlugin(globalOutput, name, "")
+}
+
+func removePlugin(globalOutput jsonObject, name string) {
+	lock := loadLockfile()
+	locked, found := lock.Plugins[name]
+	if !found {
+		app.Errorf("plugin %s is not installed", name)
+		os.Exit(exitCodeUserError)
+	}
+
+	err := os.RemoveAll(filepath.Join(pluginsDir(), name))
+	check(err, exitCodeInternalError, fmt.Sprintf("removing %s", name))
+
+	delete(lock.Plugins, name)
+	lock.save()
+
+	globalOutput.print("result", "ok")
+	globalOutput.print("removed", fmt.Sprintf("%s@%s", name, locked.Version))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// configDir is where watts-plugin-tester keeps installed plugins, the
+// lockfile, channel list and (see request chunk0-5) persistent defaults.
+func configDir() string {
+	home, err := os.UserHomeDir()
+	check(err, exitCodeInternalError, "determining home directory")
+	return filepath.Join(home, ".watts-plugin-tester")
+}
+
+func pluginsDir() string {
+	return filepath.Join(configDir(), "plugins")
+}
+
+func pluginInstallDir(name, version string) string {
+	return filepath.Join(pluginsDir(), name, version)
+}
+
+func lockfilePath() string {
+	return filepath.Join(configDir(), "lock.json")
+}
+
+// LockedPlugin records the installed version of a plugin and the path to
+// its executable.
+type LockedPlugin struct {
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+// Lockfile is the on-disk record of what is currently installed.
+type Lockfile struct {
+	Plugins map[string]LockedPlugin `json:"plugins"`
+}
+
+func loadLockfile() *Lockfile {
+	lock := &Lockfile{Plugins: map[string]LockedPlugin{}}
+
+	data, err := ioutil.ReadFile(lockfilePath())
+	if os.IsNotExist(err) {
+		return lock
+	}
+	check(err, exitCodeInternalError, "reading lockfile")
+	check(json.Unmarshal(data, lock), exitCodeInternalError, "parsing lockfile")
+	return lock
+}
+
+func (l *Lockfile) save() {
+	err := os.MkdirAll(configDir(), 0755)
+	check(err, exitCodeInternalError, "creating config directory")
+
+	data := marshal(l)
+	err = ioutil.WriteFile(lockfilePath(), data, 0644)
+	check(err, exitCodeInternalError, "writing lockfile")
+}
+
+// resolvePluginPath turns a bare installed plugin name into the path of its
+// executable via the lockfile. Anything that already exists as a file
+// (absolute or relative path) is returned unchanged.
+func resolvePluginPath(nameOrPath string) string {
+	if _, err := os.Stat(nameOrPath); err == nil {
+		return nameOrPath
+	}
+
+	lock := loadLockfile()
+	locked, found := lock.Plugins[nameOrPath]
+	if !found {
+		app.Errorf("'%s' is neither a file nor an installed plugin (try 'watts-plugin-tester install %s')",
+			nameOrPath, nameOrPath)
+		os.Exit(exitCodeUserError)
+	}
+	return locked.Path
+}
+
+func channelsFilePath() string {
+	return filepath.Join(configDir(), "channels.json")
+}
+
+func loadChannels() []string {
+	channels := []string{}
+
+	data, err := ioutil.ReadFile(channelsFilePath())
+	if os.IsNotExist(err) {
+		return channels
+	}
+	check(err, exitCodeInternalError, "reading channels file")
+	check(json.Unmarshal(data, &channels), exitCodeInternalError, "parsing channels file")
+	return channels
+}
+
+func saveChannels(channels []string) {
+	err := os.MkdirAll(configDir(), 0755)
+	check(err, exitCodeInternalError, "creating config directory")
+
+	data := marshal(channels)
+	err = ioutil.WriteFile(channelsFilePath(), data, 0644)
+	check(err, exitCodeInternalError, "writing channels file")
+}
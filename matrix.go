@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/indigo-dc/watts-plugin-tester/schemes"
+)
+
+var (
+	pluginMatrix = app.Command("matrix", "Test a plugin against every WATTS schema version known to the tester")
+)
+
+// runMatrix executes pluginInput against every WATTS version present in
+// schemes.WattsSchemes instead of the single version version() would pick,
+// validates each response against that version's schema and records a
+// version -> action -> ok/error/skip compatibility matrix on o. It returns
+// false if any version produced a validation error. ctx bounds the
+// per-version plugin invocations together: they all share its one deadline.
+func (o *jsonObject) runMatrix(ctx context.Context, pluginName string, pluginInput jsonObject) bool {
+	action, _ := pluginInput["action"].(string)
+	matrix := jsonObject{}
+	allOK := true
+
+	for schemeVersion, actionSchemes := range schemes.WattsSchemes {
+		versionResult := jsonObject{}
+
+		actionScheme, hasAction := actionSchemes[action]
+		if !hasAction {
+			versionResult[action] = "skip"
+			matrix[schemeVersion] = versionResult
+			continue
+		}
+
+		versionedInput := jsonObject{}
+		for k, v := range pluginInput {
+			versionedInput[k] = v
+		}
+		versionedInput["watts_version"] = schemeVersion
+
+		testOutput := jsonObject{}
+		po := testOutput.executePlugin(ctx, pluginName, versionedInput)
+
+		if _, err := actionScheme.Validate(po); err != nil {
+			versionResult[action] = "error"
+			versionResult["description"] = fmt.Sprintf("validation error: %s", err)
+			allOK = false
+		} else {
+			versionResult[action] = "ok"
+		}
+		matrix[schemeVersion] = versionResult
+	}
+
+	o.print("matrix", matrix)
+	if allOK {
+		o.print("result", "ok")
+	} else {
+		o.print("result", "error")
+	}
+	return allOK
+}
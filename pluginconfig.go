@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// toolConfig is the persistent, per-plugin store of tester defaults kept at
+// ~/.watts-plugin-tester/config.json, in the spirit of the Docker CLI's
+// simple per-plugin map[string]string config.
+type toolConfig struct {
+	PluginConfig map[string]map[string]string `json:"plugin_config"`
+}
+
+func toolConfigPath() string {
+	return filepath.Join(configDir(), "config.json")
+}
+
+func loadToolConfig() *toolConfig {
+	cfg := &toolConfig{PluginConfig: map[string]map[string]string{}}
+
+	data, err := ioutil.ReadFile(toolConfigPath())
+	if os.IsNotExist(err) {
+		return cfg
+	}
+	check(err, exitCodeInternalError, "reading config file")
+	check(json.Unmarshal(data, cfg), exitCodeInternalError, "parsing config file")
+
+	if cfg.PluginConfig == nil {
+		cfg.PluginConfig = map[string]map[string]string{}
+	}
+	return cfg
+}
+
+func (c *toolConfig) save() {
+	err := os.MkdirAll(configDir(), 0755)
+	check(err, exitCodeInternalError, "creating config directory")
+
+	err = ioutil.WriteFile(toolConfigPath(), marshal(c), 0644)
+	check(err, exitCodeInternalError, "writing config file")
+}
+
+// storedJSONKeys are plugin_config entries that hold a JSON-encoded object
+// rather than a plain string, and get merged back into the plugin input as
+// such by mergeStoredPluginConfig.
+var storedJSONKeys = []string{"conf_params", "user_info", "params"}
+
+// mergeStoredPluginConfig merges the persisted defaults for pluginName into
+// pluginInput: conf_params/user_info/params are stored JSON-encoded and
+// merged as objects, anything else is merged as a plain top-level value.
+func mergeStoredPluginConfig(pluginName string, pluginInput jsonObject) jsonObject {
+	if pluginName == "" {
+		return pluginInput
+	}
+
+	stored, found := loadToolConfig().PluginConfig[pluginName]
+	if !found {
+		return pluginInput
+	}
+
+	for _, key := range storedJSONKeys {
+		raw, ok := stored[key]
+		if !ok {
+			continue
+		}
+		var parsed map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &parsed); err == nil {
+			pluginInput[key] = parsed
+		}
+	}
+
+	for key, value := range stored {
+		isJSONKey := false
+		for _, jsonKey := range storedJSONKeys {
+			if key == jsonKey {
+				isJSONKey = true
+				break
+			}
+		}
+		if !isJSONKey {
+			pluginInput[key] = value
+		}
+	}
+	return pluginInput
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// withTempHome points configDir (and so toolConfigPath) at a scratch
+// directory for the duration of the test, so these tests never touch the
+// real ~/.watts-plugin-tester.
+func withTempHome(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	old := os.Getenv("HOME")
+	os.Setenv("HOME", home)
+	t.Cleanup(func() { os.Setenv("HOME", old) })
+}
+
+func TestMergeStoredPluginConfigNoPlugin(t *testing.T) {
+	withTempHome(t)
+
+	input := jsonObject{"action": "parameter"}
+	merged := mergeStoredPluginConfig("", input)
+	if len(merged) != 1 {
+		t.Errorf("mergeStoredPluginConfig with empty pluginName modified the input: %+v", merged)
+	}
+}
+
+func TestMergeStoredPluginConfigNoStoredDefaults(t *testing.T) {
+	withTempHome(t)
+
+	input := jsonObject{"action": "parameter"}
+	merged := mergeStoredPluginConfig("some-plugin", input)
+	if len(merged) != 1 {
+		t.Errorf("mergeStoredPluginConfig with no stored defaults modified the input: %+v", merged)
+	}
+}
+
+func TestMergeStoredPluginConfigMergesPlainAndJSONKeys(t *testing.T) {
+	withTempHome(t)
+
+	cfg := loadToolConfig()
+	cfg.PluginConfig["my-plugin"] = map[string]string{
+		"conf_params": `{"endpoint": "https://example.org"}`,
+		"env":         "production",
+	}
+	cfg.save()
+
+	merged := mergeStoredPluginConfig("my-plugin", jsonObject{"action": "parameter"})
+
+	if merged["env"] != "production" {
+		t.Errorf("merged[env] = %v, want production", merged["env"])
+	}
+
+	confParams, ok := merged["conf_params"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("merged[conf_params] = %#v, want a decoded object", merged["conf_params"])
+	}
+	if confParams["endpoint"] != "https://example.org" {
+		t.Errorf("conf_params[endpoint] = %v, want https://example.org", confParams["endpoint"])
+	}
+}
+
+func TestMergeStoredPluginConfigInvalidJSONIsLeftAsIs(t *testing.T) {
+	withTempHome(t)
+
+	cfg := loadToolConfig()
+	cfg.PluginConfig["my-plugin"] = map[string]string{
+		"user_info": "not-json",
+	}
+	cfg.save()
+
+	merged := mergeStoredPluginConfig("my-plugin", jsonObject{"action": "parameter"})
+	if _, present := merged["user_info"]; present {
+		t.Errorf("merged[user_info] should not be set for unparseable stored JSON, got %#v", merged["user_info"])
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PluginRequire describes the dependency constraints carried by a single
+// plugin version: a minimum watts-plugin-tester version and/or version
+// constraints on other plugins.
+type PluginRequire struct {
+	WattsPluginTester string            `json:"watts-plugin-tester,omitempty"`
+	Plugins           map[string]string `json:"plugins,omitempty"`
+}
+
+// PluginVersion is one installable release of a PluginPackage.
+type PluginVersion struct {
+	Version  string        `json:"version"`
+	URL      string        `json:"url"`
+	Checksum string        `json:"checksum"`
+	Require  PluginRequire `json:"require,omitempty"`
+}
+
+// PluginPackage is a single plugin as advertised by a repository index.
+type PluginPackage struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Author      string          `json:"author"`
+	Tags        []string        `json:"tags,omitempty"`
+	Versions    []PluginVersion `json:"versions"`
+}
+
+// Repository is a JSON document listing the plugin packages it serves.
+type Repository struct {
+	Name    string          `json:"name"`
+	Plugins []PluginPackage `json:"plugins"`
+}
+
+// channelIndex is the document a channel URL points to: a list of
+// repository URLs to fetch and merge.
+type channelIndex struct {
+	Repositories []string `json:"repositories"`
+}
+
+func fetchJSON(url string, v interface{}) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// fetchChannelRepositories resolves a channel URL into the repositories it
+// references, downloading and decoding each one.
+func fetchChannelRepositories(channelURL string) ([]Repository, error) {
+	var idx channelIndex
+	if err := fetchJSON(channelURL, &idx); err != nil {
+		return nil, fmt.Errorf("fetching channel index %s: %s", channelURL, err)
+	}
+
+	repos := make([]Repository, 0, len(idx.Repositories))
+	for _, repoURL := range idx.Repositories {
+		var repo Repository
+		if err := fetchJSON(repoURL, &repo); err != nil {
+			return nil, fmt.Errorf("fetching repository %s: %s", repoURL, err)
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+// fetchAllRepositories fetches and merges the repositories of every
+// configured channel.
+func fetchAllRepositories(channels []string) ([]Repository, error) {
+	all := []Repository{}
+	for _, channelURL := range channels {
+		repos, err := fetchChannelRepositories(channelURL)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, repos...)
+	}
+	return all, nil
+}
+
+func findPluginPackage(repos []Repository, name string) (*PluginPackage, bool) {
+	for i := range repos {
+		for j := range repos[i].Plugins {
+			if repos[i].Plugins[j].Name == name {
+				return &repos[i].Plugins[j], true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+var (
+	reportFormat = pluginTests.Flag("report-format", "Emit a machine-parseable test report: junit, tap or json").Default("json").Enum("junit", "tap", "json")
+	reportFile   = pluginTests.Flag("report-file", "Write the test report to this file instead of stdout").String()
+)
+
+// testOutcome classifies a single runTests result for report generation,
+// distinguishing an execution error (plugin crashed / produced unparseable
+// output) from a validation failure (schema mismatch or unexpected value).
+type testOutcome struct {
+	name      string
+	duration  string
+	status    string // "pass", "failure" or "error"
+	message   string
+	systemOut string
+}
+
+func classifyTestResult(name string, testOutput jsonObject) testOutcome {
+	outcome := testOutcome{name: name, status: "pass"}
+
+	plugin, _ := testOutput["plugin"].(jsonObject)
+	if plugin != nil {
+		if d, ok := plugin["duration"].(string); ok {
+			outcome.duration = d
+		}
+		if po, ok := plugin["plugin_output"].(string); ok {
+			outcome.systemOut = po
+		}
+		if plugin["result"] == "error" {
+			outcome.status = "error"
+			if errMsg, ok := plugin["error"].(string); ok {
+				outcome.message = errMsg
+			}
+			return outcome
+		}
+	}
+
+	if testOutput["result"] == "error" {
+		outcome.status = "failure"
+		if desc, ok := testOutput["description"].(string); ok {
+			outcome.message = desc
+		}
+	}
+	return outcome
+}
+
+// writeTestReport renders the results of a `tests` run in the format
+// requested via --report-format and writes them to --report-file, or to
+// stdout if no file was given.
+func writeTestReport(execFile string, config jsonObject, results []jsonObject) {
+	names := testNames(config)
+
+	outcomes := make([]testOutcome, len(results))
+	for i, testOutput := range results {
+		outcomes[i] = classifyTestResult(names[i], testOutput)
+	}
+
+	var report string
+	switch *reportFormat {
+	case "junit":
+		report = junitReport(execFile, outcomes)
+	case "tap":
+		report = tapReport(outcomes)
+	default:
+		// json: the results are already part of the normal output on stdout
+		if *reportFile == "" {
+			return
+		}
+		report = string(marshal(results))
+	}
+
+	if *reportFile != "" {
+		err := ioutil.WriteFile(*reportFile, []byte(report), 0644)
+		check(err, exitCodeInternalError, "writing test report")
+		return
+	}
+	fmt.Print(report)
+}
+
+func testNames(config jsonObject) []string {
+	tests, _ := config["tests"].([]interface{})
+	names := make([]string, len(tests))
+	for i, t := range tests {
+		test, _ := t.(map[string]interface{})
+		if name, ok := test["name"].(string); ok {
+			names[i] = name
+		} else {
+			names[i] = fmt.Sprintf("test_%d", i)
+		}
+	}
+	return names
+}
+
+func junitReport(execFile string, outcomes []testOutcome) string {
+	failures, errors := 0, 0
+	for _, o := range outcomes {
+		switch o.status {
+		case "failure":
+			failures++
+		case "error":
+			errors++
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&buf, "<testsuite name=\"%s\" tests=\"%d\" failures=\"%d\" errors=\"%d\">\n",
+		escapeXML(execFile), len(outcomes), failures, errors)
+
+	for _, o := range outcomes {
+		fmt.Fprintf(&buf, "  <testcase classname=\"%s\" name=\"%s\" time=\"%s\">\n",
+			escapeXML(execFile), escapeXML(o.name), escapeXML(o.duration))
+
+		switch o.status {
+		case "failure":
+			fmt.Fprintf(&buf, "    <failure message=\"%s\"></failure>\n", escapeXML(o.message))
+		case "error":
+			fmt.Fprintf(&buf, "    <error message=\"%s\"></error>\n", escapeXML(o.message))
+		}
+
+		if o.systemOut != "" {
+			fmt.Fprintf(&buf, "    <system-out><![CDATA[%s]]></system-out>\n", escapeCDATA(o.systemOut))
+		}
+		buf.WriteString("  </testcase>\n")
+	}
+
+	buf.WriteString("</testsuite>\n")
+	return buf.String()
+}
+
+func escapeXML(s string) string {
+	replacer := strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		"\"", "&quot;",
+	)
+	return replacer.Replace(s)
+}
+
+// escapeCDATA splits any literal "]]>" in s so it can't prematurely close
+// the CDATA section it's embedded in.
+func escapeCDATA(s string) string {
+	return strings.ReplaceAll(s, "]]>", "]]]]><![CDATA[>")
+}
+
+func tapReport(outcomes []testOutcome) string {
+	var buf bytes.Buffer
+	buf.WriteString("TAP version 13\n")
+	fmt.Fprintf(&buf, "1..%d\n", len(outcomes))
+
+	for i, o := range outcomes {
+		if o.status == "pass" {
+			fmt.Fprintf(&buf, "ok %d - %s\n", i+1, o.name)
+			continue
+		}
+
+		fmt.Fprintf(&buf, "not ok %d - %s\n", i+1, o.name)
+		buf.WriteString("  ---\n")
+		fmt.Fprintf(&buf, "  expected: ok\n  got: %s\n  duration: %s\n", o.status, o.duration)
+		if o.message != "" {
+			fmt.Fprintf(&buf, "  message: %q\n", o.message)
+		}
+		buf.WriteString("  ...\n")
+	}
+	return buf.String()
+}
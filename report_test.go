@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyTestResultPass(t *testing.T) {
+	testOutput := jsonObject{
+		"plugin": jsonObject{
+			"result":   "ok",
+			"duration": "1ms",
+		},
+		"result": "ok",
+	}
+
+	o := classifyTestResult("t1", testOutput)
+	if o.status != "pass" {
+		t.Errorf("status = %q, want pass", o.status)
+	}
+	if o.duration != "1ms" {
+		t.Errorf("duration = %q, want 1ms", o.duration)
+	}
+}
+
+func TestClassifyTestResultPluginError(t *testing.T) {
+	testOutput := jsonObject{
+		"plugin": jsonObject{
+			"result": "error",
+			"error":  "plugin execution timed out",
+		},
+	}
+
+	o := classifyTestResult("t1", testOutput)
+	if o.status != "error" {
+		t.Errorf("status = %q, want error", o.status)
+	}
+	if o.message != "plugin execution timed out" {
+		t.Errorf("message = %q, want %q", o.message, "plugin execution timed out")
+	}
+}
+
+func TestClassifyTestResultValidationFailure(t *testing.T) {
+	testOutput := jsonObject{
+		"result":      "error",
+		"description": "output did not match expected value",
+	}
+
+	o := classifyTestResult("t1", testOutput)
+	if o.status != "failure" {
+		t.Errorf("status = %q, want failure", o.status)
+	}
+	if o.message != "output did not match expected value" {
+		t.Errorf("message = %q, want %q", o.message, "output did not match expected value")
+	}
+}
+
+func TestEscapeCDATA(t *testing.T) {
+	in := `before]]>after`
+	want := `before]]]]><![CDATA[>after`
+	if got := escapeCDATA(in); got != want {
+		t.Errorf("escapeCDATA(%q) = %q, want %q", in, got, want)
+	}
+}
+
+func TestJunitReportEscapesCDATATerminator(t *testing.T) {
+	outcomes := []testOutcome{
+		{name: "t1", status: "pass", systemOut: "plugin printed ]]> as part of its output"},
+	}
+
+	report := junitReport("exec", outcomes)
+	if strings.Contains(report, "]]> as part") {
+		t.Error("junitReport: CDATA terminator in plugin output was not escaped")
+	}
+	if !strings.Contains(report, "]]]]><![CDATA[>") {
+		t.Error("junitReport: expected escaped CDATA terminator sequence in report")
+	}
+}
+
+func TestJunitReportCounts(t *testing.T) {
+	outcomes := []testOutcome{
+		{name: "t1", status: "pass"},
+		{name: "t2", status: "failure", message: "mismatch"},
+		{name: "t3", status: "error", message: "crashed"},
+	}
+
+	report := junitReport("exec", outcomes)
+	if !strings.Contains(report, `tests="3" failures="1" errors="1"`) {
+		t.Errorf("junitReport: unexpected header in %s", report)
+	}
+}
+
+func TestTapReport(t *testing.T) {
+	outcomes := []testOutcome{
+		{name: "t1", status: "pass"},
+		{name: "t2", status: "failure", message: "mismatch"},
+	}
+
+	report := tapReport(outcomes)
+	if !strings.Contains(report, "1..2") {
+		t.Errorf("tapReport: missing plan line in %s", report)
+	}
+	if !strings.Contains(report, "ok 1 - t1") {
+		t.Errorf("tapReport: missing pass line in %s", report)
+	}
+	if !strings.Contains(report, "not ok 2 - t2") {
+		t.Errorf("tapReport: missing failure line in %s", report)
+	}
+}
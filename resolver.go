@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// resolvePluginVersion picks the PluginVersion of pkg matching constraint.
+// An empty constraint resolves to the highest semver version.
+func resolvePluginVersion(pkg *PluginPackage, constraint string) (*PluginVersion, error) {
+	if len(pkg.Versions) == 0 {
+		return nil, fmt.Errorf("plugin %s has no published versions", pkg.Name)
+	}
+
+	if constraint == "" {
+		latest := &pkg.Versions[0]
+		for i := 1; i < len(pkg.Versions); i++ {
+			if compareSemver(latest.Version, pkg.Versions[i].Version) < 0 {
+				latest = &pkg.Versions[i]
+			}
+		}
+		return latest, nil
+	}
+
+	for i := range pkg.Versions {
+		if pkg.Versions[i].Version == constraint {
+			return &pkg.Versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("plugin %s has no version matching %s", pkg.Name, constraint)
+}
+
+// resolveDependencies walks the require graph of a plugin transitively,
+// returning a flat install plan (plugin name -> version to install). It
+// fails if two requirements on the same plugin resolve to different
+// versions.
+func resolveDependencies(repos []Repository, name, constraint string) (map[string]*PluginVersion, error) {
+	plan := map[string]*PluginVersion{}
+
+	var walk func(name, constraint string) error
+	walk = func(name, constraint string) error {
+		pkg, found := findPluginPackage(repos, name)
+		if !found {
+			return fmt.Errorf("plugin %s not found in any configured channel", name)
+		}
+
+		version, err := resolvePluginVersion(pkg, constraint)
+		if err != nil {
+			return err
+		}
+
+		if required := version.Require.WattsPluginTester; required != "" && compareSemver(toolVersion, required) < 0 {
+			return fmt.Errorf("plugin %s %s requires watts-plugin-tester >= %s, this is %s",
+				name, version.Version, required, toolVersion)
+		}
+
+		if existing, ok := plan[name]; ok {
+			if existing.Version != version.Version {
+				return fmt.Errorf("conflicting version constraints for plugin %s: %s vs %s",
+					name, existing.Version, version.Version)
+			}
+			return nil
+		}
+		plan[name] = version
+
+		for depName, depConstraint := range version.Require.Plugins {
+			if err := walk(depName, depConstraint); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := walk(name, constraint); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// compareSemver compares two "x.y.z" version strings, returning -1, 0 or 1.
+// Missing or non-numeric components are treated as 0.
+func compareSemver(a, b string) int {
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+	for i := 0; i < 3; i++ {
+		na, nb := semverPart(pa, i), semverPart(pb, i)
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func semverPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	n, _ := strconv.Atoi(parts[i])
+	return n
+}
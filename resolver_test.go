@@ -0,0 +1,123 @@
+package main
+
+import "testing"
+
+func TestCompareSemver(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.1.0", "1.0.9", 1},
+		{"2.0.0", "1.9.9", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.2.3", "1.2", 1},
+		{"", "0.0.1", -1},
+	}
+
+	for _, c := range cases {
+		if got := compareSemver(c.a, c.b); got != c.want {
+			t.Errorf("compareSemver(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestResolveDependenciesSimple(t *testing.T) {
+	repos := []Repository{
+		{
+			Name: "test",
+			Plugins: []PluginPackage{
+				{
+					Name: "a",
+					Versions: []PluginVersion{
+						{Version: "1.0.0", Require: PluginRequire{Plugins: map[string]string{"b": "1.0.0"}}},
+					},
+				},
+				{
+					Name: "b",
+					Versions: []PluginVersion{
+						{Version: "1.0.0"},
+					},
+				},
+			},
+		},
+	}
+
+	plan, err := resolveDependencies(repos, "a", "")
+	if err != nil {
+		t.Fatalf("resolveDependencies: unexpected error: %s", err)
+	}
+	if len(plan) != 2 {
+		t.Fatalf("resolveDependencies: got %d plugins, want 2", len(plan))
+	}
+	if plan["a"].Version != "1.0.0" || plan["b"].Version != "1.0.0" {
+		t.Errorf("resolveDependencies: unexpected plan %+v", plan)
+	}
+}
+
+func TestResolveDependenciesConflict(t *testing.T) {
+	repos := []Repository{
+		{
+			Name: "test",
+			Plugins: []PluginPackage{
+				{
+					Name: "root",
+					Versions: []PluginVersion{
+						{Version: "1.0.0", Require: PluginRequire{Plugins: map[string]string{
+							"a": "",
+							"b": "",
+						}}},
+					},
+				},
+				{
+					Name: "a",
+					Versions: []PluginVersion{
+						{Version: "1.0.0", Require: PluginRequire{Plugins: map[string]string{
+							"c": "1.0.0",
+						}}},
+					},
+				},
+				{
+					Name: "b",
+					Versions: []PluginVersion{
+						{Version: "1.0.0", Require: PluginRequire{Plugins: map[string]string{
+							"c": "2.0.0",
+						}}},
+					},
+				},
+				{
+					Name: "c",
+					Versions: []PluginVersion{
+						{Version: "1.0.0"},
+						{Version: "2.0.0"},
+					},
+				},
+			},
+		},
+	}
+
+	if _, err := resolveDependencies(repos, "root", ""); err == nil {
+		t.Error("resolveDependencies: expected a conflicting version constraints error")
+	}
+}
+
+func TestResolveDependenciesMinToolVersion(t *testing.T) {
+	repos := []Repository{
+		{
+			Name: "test",
+			Plugins: []PluginPackage{
+				{
+					Name: "a",
+					Versions: []PluginVersion{
+						{Version: "1.0.0", Require: PluginRequire{WattsPluginTester: "99.0.0"}},
+					},
+				},
+			},
+		},
+	}
+
+	if _, err := resolveDependencies(repos, "a", ""); err == nil {
+		t.Error("resolveDependencies: expected an error for a plugin requiring a newer tester version")
+	}
+}
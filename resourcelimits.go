@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	maxMemory  = pluginTests.Flag("max-memory", "Cap each plugin process's address space, e.g. 256M (Linux only)").String()
+	maxCPUTime = pluginTests.Flag("max-cpu-time", "Cap each plugin process's CPU time, e.g. 5s (Linux only)").String()
+)
+
+// rlimitExecEnv (and the value env vars below) flag a re-exec of the tester
+// binary as the setrlimit-then-exec helper described in applyResourceLimits.
+const (
+	rlimitExecEnv = "WATTS_PLUGIN_TESTER_RLIMIT_EXEC"
+	rlimitMemEnv  = "WATTS_PLUGIN_TESTER_RLIMIT_MEMORY"
+	rlimitCPUEnv  = "WATTS_PLUGIN_TESTER_RLIMIT_CPU"
+)
+
+// applyResourceLimits starts cmd, optionally capping the memory and/or CPU
+// time available to it. Go's exec.Cmd has no per-child rlimit knob, and
+// Setrlimit only ever applies to the calling process - toggling the
+// tester's own limits around Start() would cap the whole (long-running,
+// concurrent) tester rather than the child. Instead, when limits are
+// requested, cmd is pointed at the tester binary itself, invoked
+// with rlimitExecEnv set; maybeRunRlimitExecHelper (run at the top of
+// main) recognizes that env var, calls Setrlimit on itself - now the
+// forked child, not the parent - and syscall.Exec's the real plugin in
+// its place.
+func applyResourceLimits(cmd *exec.Cmd) error {
+	if *maxMemory == "" && *maxCPUTime == "" {
+		return cmd.Start()
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(env, rlimitExecEnv+"=1")
+
+	if *maxMemory != "" {
+		limit, err := parseByteSize(*maxMemory)
+		if err != nil {
+			return err
+		}
+		env = append(env, fmt.Sprintf("%s=%d", rlimitMemEnv, limit))
+	}
+
+	if *maxCPUTime != "" {
+		seconds, err := parseCPUSeconds(*maxCPUTime)
+		if err != nil {
+			return err
+		}
+		env = append(env, fmt.Sprintf("%s=%d", rlimitCPUEnv, seconds))
+	}
+
+	// cmd.Args, unchanged, becomes the helper's own argv[1:]: cmd.Args[0] is
+	// conventionally the plugin path/name, cmd.Args[1:] its arguments.
+	// maybeRunRlimitExecHelper re-execs os.Args[1] with os.Args[1:] as its
+	// argv, so cmd.Args[0] must be preserved, not dropped.
+	helperArgs := append([]string{self}, cmd.Args...)
+	cmd.Path = self
+	cmd.Args = helperArgs
+	cmd.Env = env
+
+	return cmd.Start()
+}
+
+// maybeRunRlimitExecHelper checks whether this process was re-exec'd by
+// applyResourceLimits to act as a setrlimit-then-exec helper and, if so,
+// applies the requested limits to itself and replaces its own image with
+// the real plugin via syscall.Exec - never returning on success. Called at
+// the very top of main(), before any flag parsing.
+func maybeRunRlimitExecHelper() {
+	if os.Getenv(rlimitExecEnv) != "1" {
+		return
+	}
+
+	if raw := os.Getenv(rlimitMemEnv); raw != "" {
+		limit, err := strconv.ParseUint(raw, 10, 64)
+		check(err, exitCodeInternalError, "parsing rlimit helper memory limit")
+		check(syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: limit, Max: limit}),
+			exitCodeInternalError, "setting memory limit")
+	}
+
+	if raw := os.Getenv(rlimitCPUEnv); raw != "" {
+		seconds, err := strconv.ParseUint(raw, 10, 64)
+		check(err, exitCodeInternalError, "parsing rlimit helper cpu limit")
+		check(syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: seconds, Max: seconds}),
+			exitCodeInternalError, "setting cpu limit")
+	}
+
+	os.Unsetenv(rlimitExecEnv)
+	os.Unsetenv(rlimitMemEnv)
+	os.Unsetenv(rlimitCPUEnv)
+
+	path, err := exec.LookPath(os.Args[1])
+	check(err, exitCodeInternalError, "resolving plugin path in rlimit helper")
+	check(syscall.Exec(path, os.Args[1:], os.Environ()), exitCodeInternalError, "exec'ing plugin in rlimit helper")
+}
+
+func parseByteSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := uint64(1)
+	numPart := s
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		multiplier, numPart = 1024, s[:len(s)-1]
+	case "M":
+		multiplier, numPart = 1024*1024, s[:len(s)-1]
+	case "G":
+		multiplier, numPart = 1024*1024*1024, s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseUint(numPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+func parseCPUSeconds(s string) (uint64, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	if d < time.Second {
+		return 1, nil
+	}
+	return uint64(d.Seconds()), nil
+}
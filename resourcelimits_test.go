@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+// TestMain lets a re-exec'd test binary stand in for the tester binary
+// itself: when applyResourceLimits re-execs self with rlimitExecEnv set,
+// the spawned process runs this TestMain instead of the normal test suite,
+// calls maybeRunRlimitExecHelper exactly as main() would, and never returns
+// on success (maybeRunRlimitExecHelper syscall.Exec's the real target).
+func TestMain(m *testing.M) {
+	maybeRunRlimitExecHelper()
+	os.Exit(m.Run())
+}
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]uint64{
+		"512": 512,
+		"1K":  1024,
+		"2M":  2 * 1024 * 1024,
+		"1G":  1024 * 1024 * 1024,
+		"4m":  4 * 1024 * 1024,
+	}
+
+	for input, want := range cases {
+		got, err := parseByteSize(input)
+		if err != nil {
+			t.Fatalf("parseByteSize(%q): unexpected error: %s", input, err)
+		}
+		if got != want {
+			t.Errorf("parseByteSize(%q) = %d, want %d", input, got, want)
+		}
+	}
+
+	if _, err := parseByteSize(""); err == nil {
+		t.Error("parseByteSize(\"\") should error")
+	}
+	if _, err := parseByteSize("not-a-size"); err == nil {
+		t.Error("parseByteSize(\"not-a-size\") should error")
+	}
+}
+
+func TestParseCPUSeconds(t *testing.T) {
+	cases := map[string]uint64{
+		"5s":    5,
+		"1m":    60,
+		"500ms": 1, // rounds up to the minimum enforceable RLIMIT_CPU of 1s
+	}
+
+	for input, want := range cases {
+		got, err := parseCPUSeconds(input)
+		if err != nil {
+			t.Fatalf("parseCPUSeconds(%q): unexpected error: %s", input, err)
+		}
+		if got != want {
+			t.Errorf("parseCPUSeconds(%q) = %d, want %d", input, got, want)
+		}
+	}
+
+	if _, err := parseCPUSeconds("not-a-duration"); err == nil {
+		t.Error("parseCPUSeconds(\"not-a-duration\") should error")
+	}
+}
+
+// TestApplyResourceLimitsReexecPreservesPluginPath exercises the actual
+// re-exec path end to end: applyResourceLimits re-execs this test binary,
+// whose TestMain (acting as main()) hands off to maybeRunRlimitExecHelper,
+// which must resolve and exec the original plugin path - not its arguments
+// with the path dropped, as it once did.
+func TestApplyResourceLimitsReexecPreservesPluginPath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("rlimit helper is Linux only")
+	}
+	echo, err := exec.LookPath("echo")
+	if err != nil {
+		t.Skip("echo not available")
+	}
+
+	// Deliberately exercises --max-cpu-time rather than --max-memory: a
+	// tight RLIMIT_AS can make the Go runtime itself fail to mmap before it
+	// ever reaches syscall.Exec, which isn't what this test is about.
+	oldMaxCPUTime := *maxCPUTime
+	*maxCPUTime = "5s"
+	defer func() { *maxCPUTime = oldMaxCPUTime }()
+
+	cmd := exec.Command(echo, "plugin-ran")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := applyResourceLimits(cmd); err != nil {
+		t.Fatalf("applyResourceLimits: %s", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("cmd.Wait: %s", err)
+	}
+
+	if got := out.String(); got != "plugin-ran\n" {
+		t.Errorf("plugin output = %q, want %q", got, "plugin-ran\n")
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	rpcHandshakeTimeout = 5 * time.Second
+	rpcShutdownTimeout  = 3 * time.Second
+)
+
+// rpcClient drives a long-lived plugin process that speaks length-prefixed
+// JSON over stdin/stdout, as an alternative to the one-shot exec.Command
+// calling convention used by executePlugin.
+type rpcClient struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *syncBuffer
+
+	protocolVersion string
+	schemaVersion   string
+	actions         []string
+}
+
+// syncBuffer is a bytes.Buffer guarded by a mutex, since cmd.Stderr is
+// written to by a background copier goroutine for as long as the plugin
+// process is alive, concurrently with drain reading it per call.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+// drain returns everything written since the last drain and resets the
+// buffer, so each call reports only its own stderr output.
+func (s *syncBuffer) drain() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.buf.String()
+	s.buf.Reset()
+	return out
+}
+
+// newRPCPlugin spawns pluginName once and performs the initial handshake,
+// exchanging protocol/schema versions and the actions the plugin supports.
+func newRPCPlugin(pluginName string) (*rpcClient, error) {
+	checkFileExistence(pluginName)
+
+	cmd := exec.Command(pluginName)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &syncBuffer{}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	client := &rpcClient{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		stderr: stderr,
+	}
+
+	handshakeCtx, cancel := context.WithTimeout(context.Background(), rpcHandshakeTimeout)
+	defer cancel()
+
+	handshake, err := client.callCtx(handshakeCtx, jsonObject{"action": "handshake"})
+	if err != nil {
+		return nil, fmt.Errorf("rpc handshake failed: %s", err)
+	}
+
+	if v, ok := handshake["protocol_version"].(string); ok {
+		client.protocolVersion = v
+	}
+	if v, ok := handshake["schema_version"].(string); ok {
+		client.schemaVersion = v
+	}
+	if actions, ok := handshake["actions"].([]interface{}); ok {
+		for _, a := range actions {
+			if s, ok := a.(string); ok {
+				client.actions = append(client.actions, s)
+			}
+		}
+	}
+	return client, nil
+}
+
+// call writes a single length-prefixed JSON request and reads the matching
+// length-prefixed JSON reply: <uint32 length><json payload>, both ways.
+func (c *rpcClient) call(request jsonObject) (jsonObject, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(payload)))
+	if _, err := c.stdin.Write(length); err != nil {
+		return nil, err
+	}
+	if _, err := c.stdin.Write(payload); err != nil {
+		return nil, err
+	}
+
+	replyLength := make([]byte, 4)
+	if _, err := io.ReadFull(c.stdout, replyLength); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, binary.BigEndian.Uint32(replyLength))
+	if _, err := io.ReadFull(c.stdout, reply); err != nil {
+		return nil, err
+	}
+
+	var response jsonObject
+	if err := json.Unmarshal(reply, &response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// callCtx runs call in the background and returns as soon as either it
+// completes or ctx is done, whichever comes first. On a timed-out or
+// cancelled ctx the plugin process is killed so the still-blocked read in
+// the abandoned call eventually unblocks instead of leaking forever.
+func (c *rpcClient) callCtx(ctx context.Context, request jsonObject) (jsonObject, error) {
+	type result struct {
+		response jsonObject
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := c.call(request)
+		done <- result{response, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-ctx.Done():
+		c.cmd.Process.Kill()
+		return nil, ctx.Err()
+	}
+}
+
+// shutdown asks the plugin to terminate gracefully, falling back to
+// SIGTERM and then SIGKILL if it doesn't exit within rpcShutdownTimeout.
+func (c *rpcClient) shutdown() {
+	// The shutdown message is written without waiting for a reply, so a
+	// plugin that doesn't answer it still gets SIGTERM before SIGKILL.
+	if payload, err := json.Marshal(jsonObject{"action": "shutdown"}); err == nil {
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(payload)))
+		c.stdin.Write(append(length, payload...))
+	}
+	c.stdin.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- c.cmd.Wait() }()
+
+	select {
+	case <-done:
+		return
+	case <-time.After(rpcShutdownTimeout):
+	}
+
+	c.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-done:
+		return
+	case <-time.After(rpcShutdownTimeout):
+	}
+
+	c.cmd.Process.Kill()
+	<-done
+}
+
+// executeResolvedPlugin runs a single plugin invocation for the check/test
+// commands, taking --rpc into account.
+func executeResolvedPlugin(o *jsonObject, pluginName string, pluginInput jsonObject) interface{} {
+	if !*rpcMode {
+		return o.executePlugin(context.Background(), pluginName, pluginInput)
+	}
+
+	client, err := newRPCPlugin(pluginName)
+	check(err, exitCodePluginExecutionError, "starting rpc plugin")
+	defer client.shutdown()
+
+	return o.executePluginRPC(context.Background(), client, pluginInput)
+}
+
+// executePluginRPC is the --rpc counterpart of executePlugin: it reuses an
+// already-running rpcClient instead of spawning a new process per call. ctx
+// bounds the call the same way it bounds executePlugin's exec.CommandContext.
+func (o *jsonObject) executePluginRPC(ctx context.Context, client *rpcClient, pluginInput jsonObject) (pluginOutput interface{}) {
+	plugin := jsonObject{}
+	plugin.print("name", client.cmd.Path)
+	plugin.print("input", pluginInput)
+
+	timeBeforeExec := time.Now()
+	response, err := client.callCtx(ctx, pluginInput)
+	timeAfterExec := time.Now()
+	duration := fmt.Sprintf("%s", timeAfterExec.Sub(timeBeforeExec))
+
+	if err != nil {
+		plugin.print("result", "error")
+		plugin.print("error", fmt.Sprint(err))
+		if ctx.Err() == context.DeadlineExceeded {
+			plugin.print("description", "plugin execution over rpc timed out")
+			plugin.print("timed_out", true)
+		} else {
+			plugin.print("description", "error executing the plugin over rpc")
+		}
+		setExitCode(exitCodePluginExecutionError)
+		o.print("plugin", plugin)
+		return
+	}
+
+	plugin.print("duration", duration)
+	if stderrOutput := client.stderr.drain(); stderrOutput != "" {
+		plugin.print("stderr", stderrOutput)
+	}
+
+	pluginOutput = map[string]interface{}(response)
+	plugin.print("output", pluginOutput)
+	o.print("plugin", plugin)
+	return
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// fakePlugin reads one length-prefixed JSON request off r and writes back a
+// length-prefixed JSON response built by respond, simulating the other end
+// of the pipe a real plugin process would occupy.
+func fakePlugin(t *testing.T, r io.Reader, w io.Writer, respond func(jsonObject) jsonObject) {
+	t.Helper()
+
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(r, length); err != nil {
+		t.Errorf("fakePlugin: reading request length: %s", err)
+		return
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(length))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Errorf("fakePlugin: reading request payload: %s", err)
+		return
+	}
+
+	var request jsonObject
+	if err := json.Unmarshal(payload, &request); err != nil {
+		t.Errorf("fakePlugin: unmarshalling request: %s", err)
+		return
+	}
+
+	reply, err := json.Marshal(respond(request))
+	if err != nil {
+		t.Errorf("fakePlugin: marshalling response: %s", err)
+		return
+	}
+
+	replyLength := make([]byte, 4)
+	binary.BigEndian.PutUint32(replyLength, uint32(len(reply)))
+	if _, err := w.Write(replyLength); err != nil {
+		t.Errorf("fakePlugin: writing response length: %s", err)
+		return
+	}
+	if _, err := w.Write(reply); err != nil {
+		t.Errorf("fakePlugin: writing response payload: %s", err)
+	}
+}
+
+func TestSyncBufferDrainResetsBetweenCalls(t *testing.T) {
+	var buf syncBuffer
+	buf.Write([]byte("first"))
+
+	if got := buf.drain(); got != "first" {
+		t.Errorf("drain() = %q, want %q", got, "first")
+	}
+	if got := buf.drain(); got != "" {
+		t.Errorf("second drain() = %q, want empty, previous drain should have reset the buffer", got)
+	}
+
+	buf.Write([]byte("second"))
+	if got := buf.drain(); got != "second" {
+		t.Errorf("drain() = %q, want %q", got, "second")
+	}
+}
+
+func TestSyncBufferConcurrentWriteAndDrain(t *testing.T) {
+	var buf syncBuffer
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			buf.Write([]byte("x"))
+		}
+		close(done)
+	}()
+
+	for i := 0; i < 1000; i++ {
+		buf.drain()
+	}
+	<-done
+}
+
+func TestRPCClientCallRoundTrip(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+
+	client := &rpcClient{
+		stdin:  stdinW,
+		stdout: bufio.NewReader(stdoutR),
+	}
+
+	go fakePlugin(t, stdinR, stdoutW, func(request jsonObject) jsonObject {
+		if request["action"] != "handshake" {
+			t.Errorf("fakePlugin: got action %v, want handshake", request["action"])
+		}
+		return jsonObject{"protocol_version": "1.0", "actions": []interface{}{"parameter"}}
+	})
+
+	response, err := client.call(jsonObject{"action": "handshake"})
+	if err != nil {
+		t.Fatalf("call: unexpected error: %s", err)
+	}
+	if response["protocol_version"] != "1.0" {
+		t.Errorf("response[protocol_version] = %v, want 1.0", response["protocol_version"])
+	}
+}
+
+func TestRPCClientCallCtxReturnsBeforeDeadline(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	stdoutR, stdoutW := io.Pipe()
+
+	client := &rpcClient{
+		stdin:  stdinW,
+		stdout: bufio.NewReader(stdoutR),
+	}
+
+	go fakePlugin(t, stdinR, stdoutW, func(request jsonObject) jsonObject {
+		return jsonObject{"result": "ok"}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	response, err := client.callCtx(ctx, jsonObject{"action": "parameter"})
+	if err != nil {
+		t.Fatalf("callCtx: unexpected error: %s", err)
+	}
+	if response["result"] != "ok" {
+		t.Errorf("response[result] = %v, want ok", response["result"])
+	}
+}
+
+func TestRPCClientCallCtxTimesOut(t *testing.T) {
+	stdinR, stdinW := io.Pipe()
+	_, stdoutW := io.Pipe()
+	defer stdinR.Close()
+	defer stdoutW.Close()
+
+	cmd := exec.Command("sleep", "5")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("skipping: could not start helper process: %s", err)
+	}
+	defer cmd.Process.Kill()
+
+	client := &rpcClient{
+		stdin:  stdinW,
+		stdout: bufio.NewReader(stdinR), // never receives a reply
+		cmd:    cmd,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.callCtx(ctx, jsonObject{"action": "parameter"})
+	if err != context.DeadlineExceeded {
+		t.Errorf("callCtx: err = %v, want context.DeadlineExceeded", err)
+	}
+}
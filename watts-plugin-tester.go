@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -13,13 +14,21 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type jsonObject map[string]interface{}
 
+// toolVersion is this build's version, used both for app.Version and to
+// check a plugin's "require.watts-plugin-tester" constraint in resolver.go.
+const toolVersion = "2.0.0"
+
 var (
 	exitCode                     = 0
+	exitCodeMu                   sync.Mutex // guards exitCode, set from runTests' concurrent workers
 	exitCodePluginError          = 1
 	exitCodePluginExecutionError = 2
 	exitCodeInternalError        = 3
@@ -38,14 +47,18 @@ var (
 	useEnvForParameterPass = app.Flag("env", "Use this environment variable to pass the plugin input to the plugin").Short('e').Bool()
 	envVarForParameterPass = app.Flag("env-var", "This environment variable is used to pass the plugin input to the plugin").Default("WATTS_PARAMETER").String()
 
+	rpcMode = app.Flag("rpc", "Treat the plugin as a long-lived process speaking length-prefixed JSON over stdio instead of a one-shot executable").Bool()
+
 	pluginCheck = app.Command("check", "Check a plugin against the inbuilt typed schema")
 
 	pluginTest           = app.Command("test", "Test a plugin against the inbuilt typed schema and expected output values. Provide an expected json")
 	expectedOutputFile   = pluginTest.Flag("expected-output-file", "Expected output as a file").String()
 	expectedOutputString = pluginTest.Flag("expected-output-string", "Expected output as a string").String()
 
-	pluginTests       = app.Command("tests", "Test a plugin using test config")
-	pluginTestsConfig = pluginTests.Arg("config", "Config file for the tests to run").Required().String()
+	pluginTests        = app.Command("tests", "Test a plugin using test config")
+	pluginTestsConfig  = pluginTests.Arg("config", "Config file for the tests to run").Required().String()
+	testJobs           = pluginTests.Flag("jobs", "Number of tests to run concurrently, defaults to the number of CPUs").Int()
+	testDefaultTimeout = pluginTests.Flag("timeout", "Default per-test timeout, e.g. 5s; overridable per test via a \"timeout\" field").Default("30s").String()
 
 	printDefault = app.Command("default", "Print the default plugin input as json")
 
@@ -90,6 +103,21 @@ func checkFileExistence(name string) {
 	check(err, exitCodeUserError, "")
 }
 
+// setExitCode records the process's exit code under exitCodeMu. Needed
+// because runTests' worker pool invokes executePlugin/executePluginRPC from
+// multiple goroutines, any of which may set the global exitCode.
+func setExitCode(code int) {
+	exitCodeMu.Lock()
+	exitCode = code
+	exitCodeMu.Unlock()
+}
+
+func getExitCode() int {
+	exitCodeMu.Lock()
+	defer exitCodeMu.Unlock()
+	return exitCode
+}
+
 func jsonFileToObject(file string) jsonObject {
 	checkFileExistence(file)
 	overrideBytes, err := ioutil.ReadFile(file)
@@ -192,6 +220,9 @@ func marshalPluginInput(pluginInput jsonObject) (s []byte) {
 func specifyPluginInput(pluginInput jsonObject) (specificPluginInput jsonObject) {
 	specificPluginInput = pluginInput
 
+	// merge the persisted per-plugin defaults before any --input-* overrides
+	specificPluginInput = mergeStoredPluginConfig(*pluginName, specificPluginInput)
+
 	// merge a user provided watts config
 	if *inputComplementConf != "" {
 		checkFileExistence(*inputComplementConf)
@@ -273,7 +304,7 @@ func getExpectedOutput() (expectedOutput jsonObject) {
 }
 
 // plugin execution
-func (o *jsonObject) executePlugin(pluginName string, pluginInput jsonObject) (pluginOutput interface{}) {
+func (o *jsonObject) executePlugin(ctx context.Context, pluginName string, pluginInput jsonObject) (pluginOutput interface{}) {
 	checkFileExistence(pluginName)
 	inputBase64 := base64.StdEncoding.EncodeToString(marshalPluginInput(pluginInput))
 
@@ -283,23 +314,37 @@ func (o *jsonObject) executePlugin(pluginName string, pluginInput jsonObject) (p
 
 	var cmd *exec.Cmd
 	if *useEnvForParameterPass {
-		cmd = exec.Command(pluginName)
+		cmd = exec.CommandContext(ctx, pluginName)
 		cmd.Env = []string{fmt.Sprintf("%s=%s", *envVarForParameterPass, inputBase64)}
 	} else {
-		cmd = exec.Command(pluginName, inputBase64)
+		cmd = exec.CommandContext(ctx, pluginName, inputBase64)
 	}
 
+	var outputBuffer bytes.Buffer
+	cmd.Stdout = &outputBuffer
+	cmd.Stderr = &outputBuffer
+
 	timeBeforeExec := time.Now()
-	outputBytes, err := cmd.CombinedOutput()
+	err := applyResourceLimits(cmd)
+	if err == nil {
+		err = cmd.Wait()
+	}
 	timeAfterExec := time.Now()
 	duration := fmt.Sprintf("%s", timeAfterExec.Sub(timeBeforeExec))
+	outputBytes := outputBuffer.Bytes()
 
 	if err != nil {
 		plugin.print("result", "error")
 		plugin.print("error", fmt.Sprint(err))
 		plugin.print("plugin_output", string(outputBytes))
-		plugin.print("description", "error executing the plugin")
-		exitCode = 3
+		if ctx.Err() == context.DeadlineExceeded {
+			plugin.print("description", "plugin execution timed out")
+			plugin.print("timed_out", true)
+		} else {
+			plugin.print("description", "error executing the plugin")
+		}
+		setExitCode(3)
+		o.print("plugin", plugin)
 		return
 	}
 
@@ -310,7 +355,8 @@ func (o *jsonObject) executePlugin(pluginName string, pluginInput jsonObject) (p
 		plugin.print("result", "error")
 		plugin.print("description", "Error processing the output of the plugin")
 		plugin.print("error", fmt.Sprint(err))
-		exitCode = 1
+		setExitCode(1)
+		o.print("plugin", plugin)
 		return
 	}
 
@@ -358,7 +404,7 @@ func (o *jsonObject) testPluginOutput(pluginOutput interface{}, pluginInput json
 }
 
 func (o *jsonObject) generateConfParams(pluginName string, pluginInput jsonObject) jsonObject {
-	po := o.executePlugin(pluginName, pluginInput)
+	po := o.executePlugin(context.Background(), pluginName, pluginInput)
 	confParamsInterface := po.(map[string]([]interface{}))["conf_params"]
 
 	confParams := map[string]interface{}{}
@@ -370,74 +416,197 @@ func (o *jsonObject) generateConfParams(pluginName string, pluginInput jsonObjec
 	return pluginInput
 }
 
+// isRPCMode decides whether a test config should be run against a
+// long-lived RPC plugin: either the global --rpc flag is set, or the
+// config itself requests it via a "mode": "rpc" field.
+func isRPCMode(config jsonObject) bool {
+	if *rpcMode {
+		return true
+	}
+	mode, _ := config["mode"].(string)
+	return mode == "rpc"
+}
+
+// testTimeoutFor resolves the timeout to use for a single test: its own
+// "timeout" field if present and valid, otherwise the --timeout default.
+func testTimeoutFor(test map[string]interface{}, defaultTimeout time.Duration) time.Duration {
+	if raw, ok := test["timeout"].(string); ok {
+		if d, err := time.ParseDuration(raw); err == nil {
+			return d
+		}
+	}
+	return defaultTimeout
+}
+
+// runTests runs every test in config concurrently across a worker pool
+// sized by --jobs (defaulting to runtime.NumCPU()), each under its own
+// context.WithTimeout, while preserving the tests' original order in the
+// aggregated output.
 func (o *jsonObject) runTests(config jsonObject) bool {
 	pluginName := config["exec_file"].(string)
 	tests := config["tests"].([]interface{})
-	testResultList := []jsonObject{}
-	testResult := map[string]int{"total": 0, "passed": 0, "failed": 0}
+	testResultList := make([]jsonObject, len(tests))
+
+	var client *rpcClient
+	if isRPCMode(config) {
+		var err error
+		client, err = newRPCPlugin(pluginName)
+		check(err, exitCodePluginExecutionError, "starting rpc plugin")
+		defer client.shutdown()
+	}
 
-	for _, t := range tests {
-		testResult["total"]++
+	matrixMode, _ := config["matrix"].(bool)
 
-		testOutput := jsonObject{}
-		test := t.(map[string]interface{})
-		pi := jsonObject(test["input"].(map[string]interface{}))
-		eo := jsonObject(test["expected_output"].(map[string]interface{}))
-		po := testOutput.executePlugin(pluginName, pi)
+	defaultTimeout, err := time.ParseDuration(*testDefaultTimeout)
+	check(err, exitCodeUserError, fmt.Sprintf("parsing --timeout %s", *testDefaultTimeout))
 
-		if testOutput.testPluginOutput(po, pi, eo) {
-			testResult["passed"]++
-		} else {
-			testResult["failed"]++
-		}
-		testResultList = append(testResultList, testOutput)
+	jobs := *testJobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	var wg sync.WaitGroup
+	var rpcCallMu sync.Mutex // serializes calls against the single shared rpcClient
+	semaphore := make(chan struct{}, jobs)
+	passed, failed := int32(0), int32(0)
+
+	for i, t := range tests {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(i int, t interface{}) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			testOutput := jsonObject{}
+			test := t.(map[string]interface{})
+			pi := jsonObject(test["input"].(map[string]interface{}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeoutFor(test, defaultTimeout))
+			defer cancel()
+
+			ok := false
+			switch {
+			case matrixMode:
+				ok = testOutput.runMatrix(ctx, pluginName, pi)
+			case client != nil:
+				rpcCallMu.Lock()
+				po := testOutput.executePluginRPC(ctx, client, pi)
+				rpcCallMu.Unlock()
+				eo := jsonObject(test["expected_output"].(map[string]interface{}))
+				ok = testOutput.testPluginOutput(po, pi, eo)
+			default:
+				po := testOutput.executePlugin(ctx, pluginName, pi)
+				eo := jsonObject(test["expected_output"].(map[string]interface{}))
+				ok = testOutput.testPluginOutput(po, pi, eo)
+			}
+
+			testResultList[i] = testOutput
+			if ok {
+				atomic.AddInt32(&passed, 1)
+			} else {
+				atomic.AddInt32(&failed, 1)
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	testResult := map[string]int{
+		"total":  len(tests),
+		"passed": int(passed),
+		"failed": int(failed),
 	}
+
 	o.print("tests", testResultList)
 	o.print("result", "ok")
 	o.print("stats", testResult)
 
-	if testResult["failed"] > 0 {
-		return false
-	} else {
-		return true
-	}
+	return failed == 0
 }
 
 // main
 func main() {
+	maybeRunRlimitExecHelper()
+
 	app.Author("Lukas Burgey @ KIT within the INDIGO DataCloud Project")
-	app.Version("2.0.0")
+	app.Version(toolVersion)
 	globalOutput := jsonObject{}
 
 	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
 	case pluginCheck.FullCommand():
 		pi := specifyPluginInput(defaultPluginInput)
-		po := globalOutput.executePlugin(*pluginName, pi)
+		po := executeResolvedPlugin(&globalOutput, resolvePluginPath(*pluginName), pi)
 		if !globalOutput.checkPluginOutput(po, pi) {
-			exitCode = exitCodePluginError
+			setExitCode(exitCodePluginError)
 		}
 
 	case pluginTest.FullCommand():
 		pi := specifyPluginInput(defaultPluginInput)
-		po := globalOutput.executePlugin(*pluginName, pi)
+		po := executeResolvedPlugin(&globalOutput, resolvePluginPath(*pluginName), pi)
 		eo := getExpectedOutput()
 		if !globalOutput.testPluginOutput(po, pi, eo) {
-			exitCode = exitCodePluginError
+			setExitCode(exitCodePluginError)
+		}
+
+	case pluginMatrix.FullCommand():
+		pi := specifyPluginInput(defaultPluginInput)
+		if !globalOutput.runMatrix(context.Background(), resolvePluginPath(*pluginName), pi) {
+			setExitCode(exitCodePluginError)
 		}
 
 	case pluginTests.FullCommand():
 		config := jsonFileToObject(*pluginTestsConfig)
+		config["exec_file"] = resolvePluginPath(config["exec_file"].(string))
 		if !globalOutput.runTests(config) {
-			exitCode = exitCodePluginError
+			setExitCode(exitCodePluginError)
+		}
+		if results, ok := globalOutput["tests"].([]jsonObject); ok {
+			writeTestReport(config["exec_file"].(string), config, results)
 		}
 
 	case generateDefault.FullCommand():
 		*machineReadable = true
 		pi := specifyPluginInput(defaultPluginInput)
-		gpi := globalOutput.generateConfParams(*pluginName, pi)
+		gpi := globalOutput.generateConfParams(resolvePluginPath(*pluginName), pi)
 		validate(gpi)
 		globalOutput = gpi
 
+	case pluginInstall.FullCommand():
+		installPlugin(globalOutput, *pluginInstallName, *pluginInstallVersion)
+
+	case pluginSearch.FullCommand():
+		searchPlugins(globalOutput, *pluginSearchTerm)
+
+	case pluginList.FullCommand():
+		listInstalledPlugins(globalOutput)
+
+	case pluginUpgrade.FullCommand():
+		upgradePlugin(globalOutput, *pluginUpgradeName)
+
+	case pluginRemove.FullCommand():
+		removePlugin(globalOutput, *pluginRemoveName)
+
+	case channelAdd.FullCommand():
+		addChannel(globalOutput, *channelAddURL)
+
+	case channelRemove.FullCommand():
+		removeChannel(globalOutput, *channelRemoveURL)
+
+	case channelList.FullCommand():
+		listChannels(globalOutput)
+
+	case configSet.FullCommand():
+		configSetCommand(globalOutput, *configSetName, *configSetKey, *configSetValue)
+
+	case configGet.FullCommand():
+		configGetCommand(globalOutput, *configGetName, *configGetKey)
+
+	case configUnset.FullCommand():
+		configUnsetCommand(globalOutput, *configUnsetName, *configUnsetKey)
+
+	case configList.FullCommand():
+		configListCommand(globalOutput, *configListName)
+
 	case printDefault.FullCommand():
 		*machineReadable = true
 		globalOutput = defaultPluginInput
@@ -448,5 +617,5 @@ func main() {
 	}
 
 	printGlobalOutput(globalOutput)
-	os.Exit(exitCode)
+	os.Exit(getExitCode())
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSetExitCodeConcurrent(t *testing.T) {
+	oldExitCode := getExitCode()
+	defer setExitCode(oldExitCode)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(code int) {
+			defer wg.Done()
+			setExitCode(code)
+		}(exitCodePluginError)
+	}
+	wg.Wait()
+
+	if got := getExitCode(); got != exitCodePluginError {
+		t.Errorf("getExitCode() = %d, want %d", got, exitCodePluginError)
+	}
+}